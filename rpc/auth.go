@@ -0,0 +1,44 @@
+package rpc
+
+const (
+	authFlavorNone = 0
+	authFlavorUnix = 1
+)
+
+// Credential is an ONC RPC authentication flavor (RFC 1057 §7): a pair of
+// opaque_auth values carried on every call, one presented as credentials
+// and one as a (usually empty) verifier.
+type Credential interface {
+	// Cred returns the XDR-encoded opaque_auth used as the call's credential.
+	Cred() []byte
+	// Verf returns the XDR-encoded opaque_auth used as the call's verifier.
+	Verf() []byte
+}
+
+// AuthNone is the null credential (AUTH_NONE): no authentication
+// information at all. It is the default when Client.Auth is unset.
+type AuthNone struct{}
+
+func (AuthNone) Cred() []byte { return stack(authFlavorNone, 0) }
+func (AuthNone) Verf() []byte { return stack(authFlavorNone, 0) }
+
+// AuthUnix is the AUTH_UNIX credential (RFC 1057 §7.2). It carries no real
+// authentication, but servers commonly use the embedded hostname, uid, and
+// gids for logging or access control.
+type AuthUnix struct {
+	Stamp   uint32
+	Machine string
+	UID     uint32
+	GID     uint32
+	GIDs    []uint32
+}
+
+func (a AuthUnix) Cred() []byte {
+	body := stack(a.Stamp)
+	body = append(body, encodeString(a.Machine)...)
+	body = append(body, stack(a.UID, a.GID)...)
+	body = append(body, encodeUint32Array(a.GIDs)...)
+	return append(stack(authFlavorUnix, uint32(len(body))), body...)
+}
+
+func (AuthUnix) Verf() []byte { return stack(authFlavorNone, 0) }
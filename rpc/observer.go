@@ -0,0 +1,31 @@
+package rpc
+
+import "time"
+
+// RPCObserver receives callbacks for the lifecycle of a Client.Call
+// transaction, borrowing the stats-handler pattern from gRPC so operators
+// can wire a Client into Prometheus, OpenTelemetry, or similar without
+// wrapping every call site. Implementations must be safe for concurrent
+// use, since a Client may be shared across goroutines.
+type RPCObserver interface {
+	// OnCallStart fires once, when a call for prog/vers/proc is about to
+	// be sent under the given xid.
+	OnCallStart(prog, vers, proc uint32, xid uint32)
+	// OnRetry fires each time the request is resent after a read
+	// timeout; attempt is the retry number, starting at 1.
+	OnRetry(xid uint32, attempt int)
+	// OnReply fires once a valid reply to xid has been received, with the
+	// size of the raw reply and the latency since the call started.
+	OnReply(xid uint32, bytes int, latency time.Duration)
+	// OnError fires if the call ends in an error, whether from the
+	// transport or from an invalid reply.
+	OnError(xid uint32, err error)
+}
+
+// noopObserver is the RPCObserver used when a Client has none configured.
+type noopObserver struct{}
+
+func (noopObserver) OnCallStart(prog, vers, proc uint32, xid uint32)      {}
+func (noopObserver) OnRetry(xid uint32, attempt int)                      {}
+func (noopObserver) OnReply(xid uint32, bytes int, latency time.Duration) {}
+func (noopObserver) OnError(xid uint32, err error)                        {}
@@ -0,0 +1,116 @@
+package rpc
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeObserver records the calls made to it, guarded by a mutex since
+// Client.Call may invoke it from the caller's goroutine while a test reads
+// its fields concurrently.
+type fakeObserver struct {
+	mu       sync.Mutex
+	started  bool
+	retries  int
+	replyXID uint32
+	replyLen int
+	errs     []error
+}
+
+func (o *fakeObserver) OnCallStart(prog, vers, proc uint32, xid uint32) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.started = true
+}
+
+func (o *fakeObserver) OnRetry(xid uint32, attempt int) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.retries++
+}
+
+func (o *fakeObserver) OnReply(xid uint32, bytes int, latency time.Duration) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.replyXID = xid
+	o.replyLen = bytes
+}
+
+func (o *fakeObserver) OnError(xid uint32, err error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.errs = append(o.errs, err)
+}
+
+type voidArgs struct{}
+
+func (voidArgs) XDREncode() ([]byte, error) { return nil, nil }
+
+// echoServer reads one UDP request and replies with a well-formed,
+// empty-body success reply echoing the request's xid.
+func echoServer(t *testing.T, conn *net.UDPConn) {
+	t.Helper()
+	buf := make([]byte, 2048)
+	n, addr, err := conn.ReadFromUDP(buf)
+	if err != nil {
+		return
+	}
+	xid := binary.BigEndian.Uint32(buf[:4])
+	_ = n
+	reply := stack(xid, msgTypeReply, replyAccepted, authFlavorNone, 0, acceptSuccess)
+	conn.WriteToUDP(reply, addr)
+}
+
+func TestObserverOnCallStartAndOnReply(t *testing.T) {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP("127.0.0.1")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	go echoServer(t, conn)
+
+	host, port, _ := net.SplitHostPort(conn.LocalAddr().String())
+	obs := &fakeObserver{}
+	cli := &Client{Host: host, Port: port, Observer: obs}
+
+	if err := cli.Call(context.Background(), 1, 1, 1, voidArgs{}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	obs.mu.Lock()
+	defer obs.mu.Unlock()
+	if !obs.started {
+		t.Errorf("expected OnCallStart to have fired")
+	}
+	if obs.replyXID == 0 {
+		t.Errorf("expected OnReply to have fired with a non-zero xid")
+	}
+	if len(obs.errs) != 0 {
+		t.Errorf("expected no errors, got %v", obs.errs)
+	}
+}
+
+func TestObserverOnRetry(t *testing.T) {
+	obs := &fakeObserver{}
+	// 192.0.2.0/24 is reserved for documentation (RFC 5737) and never
+	// routed, so every attempt times out and every retry is observed.
+	cli := &Client{Host: "192.0.2.1", Port: "792", Observer: obs, Timeout: 20 * time.Millisecond, Retries: 2, Backoff: time.Millisecond}
+
+	err := cli.Call(context.Background(), 1, 1, 1, voidArgs{}, nil)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+
+	obs.mu.Lock()
+	defer obs.mu.Unlock()
+	if obs.retries != cli.Retries {
+		t.Errorf("got %d retries observed, want %d", obs.retries, cli.Retries)
+	}
+	if len(obs.errs) != 1 {
+		t.Errorf("expected exactly one OnError call, got %d", len(obs.errs))
+	}
+}
@@ -0,0 +1,77 @@
+package rpc
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+)
+
+const (
+	portmapProg        = 100000
+	portmapVers        = 2
+	portmapProcGetPort = 3
+)
+
+// Protocol names accepted by Portmap.GetPort.
+const (
+	ProtoTCP = "tcp"
+	ProtoUDP = "udp"
+)
+
+// Portmap is a client for the portmap/rpcbind protocol (RFC 1833), used to
+// resolve an RPC program and version to the port it is currently
+// registered on.
+type Portmap struct {
+	Client *Client
+}
+
+// NewPortmap returns a Portmap client talking to the rpcbind service on
+// host's well-known port, 111.
+func NewPortmap(host string) *Portmap {
+	return &Portmap{Client: &Client{Host: host, Port: "111"}}
+}
+
+type mappingArgs struct {
+	Prog, Vers, Proto, Port uint32
+}
+
+func (m mappingArgs) XDREncode() ([]byte, error) {
+	return stack(m.Prog, m.Vers, m.Proto, m.Port), nil
+}
+
+type portReply struct {
+	Port uint32
+}
+
+func (p *portReply) XDRDecode(data []byte) error {
+	if len(data) < 4 {
+		return fmt.Errorf("rpc: short GETPORT reply")
+	}
+	p.Port = binary.BigEndian.Uint32(data[:4])
+	return nil
+}
+
+// GetPort asks rpcbind what port the given program/version is listening on
+// for proto ("tcp" or "udp"). It returns an error if no such mapping is
+// registered.
+func (p *Portmap) GetPort(ctx context.Context, prog, vers uint32, proto string) (uint32, error) {
+	var protoNum uint32
+	switch proto {
+	case ProtoTCP:
+		protoNum = 6
+	case ProtoUDP:
+		protoNum = 17
+	default:
+		return 0, fmt.Errorf("rpc: unknown protocol %q", proto)
+	}
+
+	var reply portReply
+	args := mappingArgs{Prog: prog, Vers: vers, Proto: protoNum}
+	if err := p.Client.Call(ctx, portmapProg, portmapVers, portmapProcGetPort, args, &reply); err != nil {
+		return 0, fmt.Errorf("rpc: rpcbind request failed: %v", err)
+	}
+	if reply.Port == 0 {
+		return 0, fmt.Errorf("rpc: no port mapping found for program %d version %d", prog, vers)
+	}
+	return reply.Port, nil
+}
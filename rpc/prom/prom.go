@@ -0,0 +1,118 @@
+/*
+Package prom is an example rpc.RPCObserver that records RPC transactions as
+Prometheus metrics: a call counter, a retry counter, an error counter, and a
+latency histogram, each labeled by program/version/procedure.
+
+	observer := prom.NewObserver(prometheus.DefaultRegisterer)
+	cli := rpc.Client{Host: "10.0.0.1", Port: "792", Observer: observer}
+*/
+package prom
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/yhat/rstatd/rpc"
+)
+
+// Observer is an rpc.RPCObserver backed by Prometheus metrics.
+type Observer struct {
+	callsTotal   *prometheus.CounterVec
+	retriesTotal *prometheus.CounterVec
+	errorsTotal  *prometheus.CounterVec
+	latency      *prometheus.HistogramVec
+
+	mu      sync.Mutex
+	pending map[uint32]procLabels
+}
+
+type procLabels struct {
+	prog, vers, proc string
+}
+
+// NewObserver creates an Observer and registers its metrics with reg. If
+// reg is nil, prometheus.DefaultRegisterer is used.
+func NewObserver(reg prometheus.Registerer) *Observer {
+	if reg == nil {
+		reg = prometheus.DefaultRegisterer
+	}
+
+	labelNames := []string{"prog", "vers", "proc"}
+	o := &Observer{
+		callsTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "rstatd_rpc_calls_total",
+			Help: "Total number of RPC calls started.",
+		}, labelNames),
+		retriesTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "rstatd_rpc_retries_total",
+			Help: "Total number of RPC retransmissions sent after a read timeout.",
+		}, labelNames),
+		errorsTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "rstatd_rpc_errors_total",
+			Help: "Total number of RPC calls that ended in an error.",
+		}, labelNames),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "rstatd_rpc_latency_seconds",
+			Help:    "RPC round-trip latency in seconds, for calls that received a valid reply.",
+			Buckets: prometheus.DefBuckets,
+		}, labelNames),
+		pending: make(map[uint32]procLabels),
+	}
+	reg.MustRegister(o.callsTotal, o.retriesTotal, o.errorsTotal, o.latency)
+	return o
+}
+
+var _ rpc.RPCObserver = (*Observer)(nil)
+
+func (o *Observer) OnCallStart(prog, vers, proc uint32, xid uint32) {
+	labels := procLabels{
+		prog: strconv.FormatUint(uint64(prog), 10),
+		vers: strconv.FormatUint(uint64(vers), 10),
+		proc: strconv.FormatUint(uint64(proc), 10),
+	}
+
+	o.mu.Lock()
+	o.pending[xid] = labels
+	o.mu.Unlock()
+
+	o.callsTotal.WithLabelValues(labels.prog, labels.vers, labels.proc).Inc()
+}
+
+func (o *Observer) OnRetry(xid uint32, attempt int) {
+	if labels, ok := o.labelsFor(xid); ok {
+		o.retriesTotal.WithLabelValues(labels.prog, labels.vers, labels.proc).Inc()
+	}
+}
+
+func (o *Observer) OnReply(xid uint32, bytes int, latency time.Duration) {
+	if labels, ok := o.forget(xid); ok {
+		o.latency.WithLabelValues(labels.prog, labels.vers, labels.proc).Observe(latency.Seconds())
+	}
+}
+
+func (o *Observer) OnError(xid uint32, err error) {
+	if labels, ok := o.forget(xid); ok {
+		o.errorsTotal.WithLabelValues(labels.prog, labels.vers, labels.proc).Inc()
+	}
+}
+
+func (o *Observer) labelsFor(xid uint32) (procLabels, bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	labels, ok := o.pending[xid]
+	return labels, ok
+}
+
+// forget returns the labels recorded for xid, if any, and stops tracking
+// it: every call ends in exactly one OnReply or OnError, so this is where
+// pending's entry for that call is cleaned up.
+func (o *Observer) forget(xid uint32) (procLabels, bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	labels, ok := o.pending[xid]
+	delete(o.pending, xid)
+	return labels, ok
+}
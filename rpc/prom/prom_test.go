@@ -0,0 +1,98 @@
+package prom
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestObserverRecordsASuccessfulCall(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	o := NewObserver(reg)
+
+	o.OnCallStart(100001, 3, 1, 42)
+	o.OnReply(42, 128, 5*time.Millisecond)
+
+	metrics, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var calls, latencyCount float64
+	var latencyCountSet bool
+	for _, mf := range metrics {
+		switch mf.GetName() {
+		case "rstatd_rpc_calls_total":
+			calls = sumCounters(mf)
+		case "rstatd_rpc_latency_seconds":
+			latencyCount = sumHistogramCounts(mf)
+			latencyCountSet = true
+		}
+	}
+	if calls != 1 {
+		t.Errorf("got %v calls, want 1", calls)
+	}
+	if !latencyCountSet || latencyCount != 1 {
+		t.Errorf("got %v latency observations, want 1", latencyCount)
+	}
+}
+
+func TestObserverRecordsRetriesAndDoesNotRecordLatencyOnError(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	o := NewObserver(reg)
+
+	o.OnCallStart(100001, 3, 1, 7)
+	o.OnRetry(7, 1)
+	o.OnRetry(7, 2)
+	o.OnError(7, errTimeout)
+
+	metrics, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var retries, errors, latencyCount float64
+	for _, mf := range metrics {
+		switch mf.GetName() {
+		case "rstatd_rpc_retries_total":
+			retries = sumCounters(mf)
+		case "rstatd_rpc_errors_total":
+			errors = sumCounters(mf)
+		case "rstatd_rpc_latency_seconds":
+			latencyCount = sumHistogramCounts(mf)
+		}
+	}
+	if retries != 2 {
+		t.Errorf("got %v retries, want 2", retries)
+	}
+	if errors != 1 {
+		t.Errorf("got %v errors, want 1", errors)
+	}
+	if latencyCount != 0 {
+		t.Errorf("got %v latency observations, want 0", latencyCount)
+	}
+}
+
+var errTimeout = fakeError("timeout")
+
+type fakeError string
+
+func (e fakeError) Error() string { return string(e) }
+
+func sumCounters(mf *dto.MetricFamily) float64 {
+	var total float64
+	for _, m := range mf.GetMetric() {
+		total += m.GetCounter().GetValue()
+	}
+	return total
+}
+
+func sumHistogramCounts(mf *dto.MetricFamily) float64 {
+	var total float64
+	for _, m := range mf.GetMetric() {
+		total += float64(m.GetHistogram().GetSampleCount())
+	}
+	return total
+}
@@ -0,0 +1,259 @@
+/*
+Package rpc implements a minimal Sun/ONC RPC (RFC 1057) client.
+
+It factors out the transaction machinery that every RPC-based protocol
+needs: XID generation, the CALL message header, reply validation, and
+retransmission over an unreliable transport. A caller only has to supply
+the program, version, and procedure numbers along with the XDR encoding of
+its arguments and results.
+
+	var reply someReply
+	err := client.Call(ctx, prog, vers, proc, someArgs{}, &reply)
+
+Use Portmap to resolve a program/version to the port it is currently
+registered on, per the portmap protocol (RFC 1833).
+*/
+package rpc
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math/rand"
+	"net"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultTimeout is the per-attempt deadline used when Client.Timeout
+	// is unset.
+	defaultTimeout = 5 * time.Second
+	// defaultRetries is the number of retransmissions attempted when
+	// Client.Retries is unset.
+	defaultRetries = 2
+	// defaultBackoff is the base delay between retries used when
+	// Client.Backoff is unset.
+	defaultBackoff = 100 * time.Millisecond
+)
+
+const (
+	rpcVersion = 2
+
+	msgTypeCall  = 0
+	msgTypeReply = 1
+
+	replyAccepted = 0
+	replyDenied   = 1
+
+	acceptSuccess      = 0
+	acceptProgUnavail  = 1
+	acceptProgMismatch = 2
+	acceptProcUnavail  = 3
+	acceptGarbageArgs  = 4
+	acceptSystemErr    = 5
+)
+
+// XDREncoder is implemented by RPC call arguments.
+type XDREncoder interface {
+	// XDREncode returns the XDR encoding of the value.
+	XDREncode() ([]byte, error)
+}
+
+// XDRDecoder is implemented by RPC call results.
+type XDRDecoder interface {
+	// XDRDecode populates the value from an XDR-encoded reply body.
+	XDRDecode(data []byte) error
+}
+
+// Client is a transport-agnostic Sun RPC client for a single host.
+type Client struct {
+	// The hostname of the RPC server.
+	// If empty, '0.0.0.0' is implied.
+	Host string
+	// The port the server is listening on.
+	Port string
+	// Network is the transport to use: "udp" (the default) or "tcp".
+	Network string
+
+	// Auth is the credential presented with each call. If nil, AuthNone
+	// is used.
+	Auth Credential
+
+	// Observer receives callbacks for each call's lifecycle events. If
+	// nil, no observability hooks are fired.
+	Observer RPCObserver
+
+	// Timeout is the deadline for a single RPC attempt. If zero,
+	// defaultTimeout is used.
+	Timeout time.Duration
+	// Retries is the number of times a request is resent after a read
+	// timeout before giving up. If zero, defaultRetries is used.
+	Retries int
+	// Backoff is the base delay before the first retry; each subsequent
+	// retry doubles the previous delay. If zero, defaultBackoff is used.
+	Backoff time.Duration
+}
+
+func (c *Client) network() string {
+	if c.Network == "" {
+		return "udp"
+	}
+	return c.Network
+}
+
+func (c *Client) addr() string {
+	host := c.Host
+	if host == "" {
+		host = "0.0.0.0"
+	}
+	return host + ":" + strings.TrimLeft(c.Port, ":")
+}
+
+func (c *Client) credential() Credential {
+	if c.Auth != nil {
+		return c.Auth
+	}
+	return AuthNone{}
+}
+
+func (c *Client) timeout() time.Duration {
+	if c.Timeout > 0 {
+		return c.Timeout
+	}
+	return defaultTimeout
+}
+
+func (c *Client) retries() int {
+	if c.Retries > 0 {
+		return c.Retries
+	}
+	return defaultRetries
+}
+
+func (c *Client) backoff() time.Duration {
+	if c.Backoff > 0 {
+		return c.Backoff
+	}
+	return defaultBackoff
+}
+
+func (c *Client) transport() Transport {
+	if c.network() == "tcp" {
+		return tcpTransport{}
+	}
+	return udpTransport{}
+}
+
+func (c *Client) observer() RPCObserver {
+	if c.Observer != nil {
+		return c.Observer
+	}
+	return noopObserver{}
+}
+
+// Call performs an RPC transaction: it dials the client's host/port, sends
+// a CALL message for the given program/version/procedure with args as the
+// argument body, and decodes the result body into reply. If an Observer is
+// configured, it is notified of the call's start, any retries, and its
+// outcome.
+func (c *Client) Call(ctx context.Context, prog, vers, proc uint32, args XDREncoder, reply XDRDecoder) error {
+	obs := c.observer()
+
+	conn, err := net.Dial(c.network(), c.addr())
+	if err != nil {
+		return fmt.Errorf("rpc: failed to connect to %s: %v", c.addr(), err)
+	}
+	defer conn.Close()
+
+	argBytes, err := args.XDREncode()
+	if err != nil {
+		return fmt.Errorf("rpc: failed to encode arguments: %v", err)
+	}
+
+	xid := rand.Uint32()
+	cred := c.credential()
+	req := make([]byte, 0, 24+len(argBytes))
+	req = append(req, stack(xid, msgTypeCall, rpcVersion, prog, vers, proc)...)
+	req = append(req, cred.Cred()...)
+	req = append(req, cred.Verf()...)
+	req = append(req, argBytes...)
+
+	start := time.Now()
+	obs.OnCallStart(prog, vers, proc, xid)
+
+	respBody, err := c.transport().Transact(ctx, conn, req, xid, c.timeout(), c.retries(), c.backoff(), obs)
+	if err != nil {
+		obs.OnError(xid, err)
+		return err
+	}
+
+	body, err := parseReply(respBody, xid)
+	if err != nil {
+		obs.OnError(xid, err)
+		return err
+	}
+
+	if reply != nil {
+		if err := reply.XDRDecode(body); err != nil {
+			obs.OnError(xid, err)
+			return err
+		}
+	}
+	obs.OnReply(xid, len(respBody), time.Since(start))
+	return nil
+}
+
+// parseReply validates an RPC reply message and returns the result body
+// that follows the header, the verifier, and the accept status.
+func parseReply(data []byte, xid uint32) ([]byte, error) {
+	if len(data) < 12 {
+		return nil, fmt.Errorf("rpc: short reply (%d bytes)", len(data))
+	}
+	next := func() uint32 {
+		v := binary.BigEndian.Uint32(data[:4])
+		data = data[4:]
+		return v
+	}
+	if got := next(); got != xid {
+		return nil, fmt.Errorf("rpc: xid mismatch: got %d, want %d", got, xid)
+	}
+	if mtype := next(); mtype != msgTypeReply {
+		return nil, fmt.Errorf("rpc: unexpected message type %d", mtype)
+	}
+	switch stat := next(); stat {
+	case replyAccepted:
+		if len(data) < 8 {
+			return nil, fmt.Errorf("rpc: short reply verifier")
+		}
+		next() // verifier flavor
+		verfLen := xdrPad(next())
+		if uint32(len(data)) < verfLen {
+			return nil, fmt.Errorf("rpc: truncated reply verifier")
+		}
+		data = data[verfLen:]
+		if len(data) < 4 {
+			return nil, fmt.Errorf("rpc: short accept status")
+		}
+		switch accept := next(); accept {
+		case acceptSuccess:
+			return data, nil
+		case acceptProgUnavail:
+			return nil, fmt.Errorf("rpc: program unavailable")
+		case acceptProgMismatch:
+			return nil, fmt.Errorf("rpc: program version mismatch")
+		case acceptProcUnavail:
+			return nil, fmt.Errorf("rpc: procedure unavailable")
+		case acceptGarbageArgs:
+			return nil, fmt.Errorf("rpc: garbage arguments")
+		case acceptSystemErr:
+			return nil, fmt.Errorf("rpc: remote system error")
+		default:
+			return nil, fmt.Errorf("rpc: unknown accept status %d", accept)
+		}
+	case replyDenied:
+		return nil, fmt.Errorf("rpc: call rejected by server")
+	default:
+		return nil, fmt.Errorf("rpc: unknown reply status %d", stat)
+	}
+}
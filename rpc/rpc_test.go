@@ -0,0 +1,52 @@
+package rpc
+
+import (
+	"context"
+	"testing"
+)
+
+func TestGetPort(t *testing.T) {
+	// rstatd, version 3, over UDP: the same program/version this module's
+	// rstatd package queries.
+	port, err := NewPortmap("0.0.0.0").GetPort(context.Background(), 100001, 3, ProtoUDP)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if port == 0 {
+		t.Errorf("expected port to be non-zero")
+	}
+}
+
+func TestParseReplySuccess(t *testing.T) {
+	xid := uint32(42)
+	reply := append(stack(xid, msgTypeReply, replyAccepted, authFlavorNone, 0, acceptSuccess), []byte("payload")...)
+	body, err := parseReply(reply, xid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != "payload" {
+		t.Errorf("got body %q, want %q", body, "payload")
+	}
+}
+
+func TestParseReplyXIDMismatch(t *testing.T) {
+	reply := stack(1, msgTypeReply, replyAccepted, authFlavorNone, 0, acceptSuccess)
+	if _, err := parseReply(reply, 2); err == nil {
+		t.Errorf("expected an xid mismatch error")
+	}
+}
+
+func TestParseReplyProgUnavail(t *testing.T) {
+	xid := uint32(7)
+	reply := stack(xid, msgTypeReply, replyAccepted, authFlavorNone, 0, acceptProgUnavail)
+	if _, err := parseReply(reply, xid); err == nil {
+		t.Errorf("expected a program unavailable error")
+	}
+}
+
+func TestAuthNoneEncoding(t *testing.T) {
+	var a AuthNone
+	if got := a.Cred(); len(got) != 8 {
+		t.Errorf("expected an 8 byte AUTH_NONE credential, got %d bytes", len(got))
+	}
+}
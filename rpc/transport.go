@@ -0,0 +1,180 @@
+package rpc
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"time"
+)
+
+// lastFragmentFlag marks the final fragment of an RPC record sent over a
+// byte stream (RFC 1057 §10).
+const lastFragmentFlag = 0x80000000
+
+// maxReplySize bounds the total size of a reassembled tcpTransport reply.
+// RFC 1057 §10 puts no limit on a fragment's length, so without a cap a
+// malformed or hostile record marker would force an arbitrarily large
+// allocation before a single byte of it is validated.
+const maxReplySize = 1 << 20 // 1 MiB
+
+// Transport sends a single RPC request over conn and returns the raw reply
+// message, retrying per the given policy where the underlying medium is
+// unreliable. obs is notified of each retry; it is never nil.
+type Transport interface {
+	Transact(ctx context.Context, conn net.Conn, req []byte, xid uint32, timeout time.Duration, retries int, backoff time.Duration, obs RPCObserver) ([]byte, error)
+}
+
+// udpTransport implements RFC 1057-style retransmission: the same request
+// (and XID) is resent on a read timeout, up to retries times, with
+// exponential backoff between attempts.
+type udpTransport struct{}
+
+func (udpTransport) Transact(ctx context.Context, conn net.Conn, req []byte, xid uint32, timeout time.Duration, retries int, backoff time.Duration, obs RPCObserver) ([]byte, error) {
+	resp := make([]byte, 2048)
+	var lastErr error
+	for attempt := 0; attempt <= retries; attempt++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		deadline := time.Now().Add(timeout)
+		if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+			deadline = ctxDeadline
+		}
+		if err := conn.SetWriteDeadline(deadline); err != nil {
+			return nil, fmt.Errorf("rpc: failed to set write deadline: %v", err)
+		}
+		if _, err := conn.Write(req); err != nil {
+			return nil, fmt.Errorf("rpc: failed to write request: %v", err)
+		}
+		if err := conn.SetReadDeadline(deadline); err != nil {
+			return nil, fmt.Errorf("rpc: failed to set read deadline: %v", err)
+		}
+
+		stop := closeOnCancel(ctx, conn)
+		n, err := conn.Read(resp)
+		stop()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			lastErr = err
+			if ne, ok := err.(net.Error); ok && ne.Timeout() {
+				if attempt < retries {
+					obs.OnRetry(xid, attempt+1)
+					if err := sleepContext(ctx, backoff<<uint(attempt)); err != nil {
+						return nil, err
+					}
+					continue
+				}
+				break
+			}
+			return nil, fmt.Errorf("rpc: failed to read response: %v", err)
+		}
+		return resp[:n], nil
+	}
+	return nil, fmt.Errorf("rpc: no reply after %d attempts: %v", retries+1, lastErr)
+}
+
+// tcpTransport frames requests and replies with RPC record marking (RFC
+// 1057 §10): each message is preceded by a 4-byte marker whose high bit
+// flags the last fragment of the record and whose low 31 bits give the
+// fragment length. TCP already retransmits lost data at the transport
+// layer, so unlike udpTransport this does not resend on timeout - doing so
+// on a partially-read stream would desync the framing.
+type tcpTransport struct{}
+
+func (tcpTransport) Transact(ctx context.Context, conn net.Conn, req []byte, xid uint32, timeout time.Duration, retries int, backoff time.Duration, obs RPCObserver) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	deadline := time.Now().Add(timeout)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		deadline = ctxDeadline
+	}
+	if err := conn.SetWriteDeadline(deadline); err != nil {
+		return nil, fmt.Errorf("rpc: failed to set write deadline: %v", err)
+	}
+	marker := stack(uint32(len(req)) | lastFragmentFlag)
+	if _, err := conn.Write(append(marker, req...)); err != nil {
+		return nil, fmt.Errorf("rpc: failed to write request: %v", err)
+	}
+	if err := conn.SetReadDeadline(deadline); err != nil {
+		return nil, fmt.Errorf("rpc: failed to set read deadline: %v", err)
+	}
+
+	stop := closeOnCancel(ctx, conn)
+	defer stop()
+
+	var body []byte
+	for {
+		var hdr [4]byte
+		if err := readFull(conn, hdr[:]); err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			return nil, fmt.Errorf("rpc: failed to read record marker: %v", err)
+		}
+		rec := binary.BigEndian.Uint32(hdr[:])
+		fragLen := rec &^ lastFragmentFlag
+		if fragLen > maxReplySize || uint32(len(body))+fragLen > maxReplySize {
+			return nil, fmt.Errorf("rpc: reply exceeds %d byte limit", maxReplySize)
+		}
+		frag := make([]byte, fragLen)
+		if err := readFull(conn, frag); err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			return nil, fmt.Errorf("rpc: failed to read record fragment: %v", err)
+		}
+		body = append(body, frag...)
+		if rec&lastFragmentFlag != 0 {
+			return body, nil
+		}
+	}
+}
+
+// readFull reads exactly len(buf) bytes from conn.
+func readFull(conn net.Conn, buf []byte) error {
+	for off := 0; off < len(buf); {
+		n, err := conn.Read(buf[off:])
+		if err != nil {
+			return err
+		}
+		off += n
+	}
+	return nil
+}
+
+// closeOnCancel starts a goroutine that closes conn if ctx is cancelled
+// before the returned stop function is called, unblocking any in-flight
+// Read so callers return ctx.Err() promptly instead of waiting out the full
+// read deadline. Callers must call stop once they're done with conn.
+func closeOnCancel(ctx context.Context, conn net.Conn) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+	return func() { close(done) }
+}
+
+// sleepContext waits for d, returning early with ctx.Err() if ctx is
+// cancelled or its deadline elapses first.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
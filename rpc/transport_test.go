@@ -0,0 +1,96 @@
+package rpc
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// tcpEchoServer accepts one connection, reads the record-marked request, and
+// writes back a well-formed success reply split across two record
+// fragments, to exercise tcpTransport's reassembly.
+func tcpEchoServer(t *testing.T, ln net.Listener) {
+	t.Helper()
+	conn, err := ln.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	var hdr [4]byte
+	if err := readFull(conn, hdr[:]); err != nil {
+		t.Errorf("server: failed to read record marker: %v", err)
+		return
+	}
+	rec := binary.BigEndian.Uint32(hdr[:])
+	req := make([]byte, rec&^lastFragmentFlag)
+	if err := readFull(conn, req); err != nil {
+		t.Errorf("server: failed to read request: %v", err)
+		return
+	}
+	xid := binary.BigEndian.Uint32(req[:4])
+
+	reply := stack(xid, msgTypeReply, replyAccepted, authFlavorNone, 0, acceptSuccess)
+	first, second := reply[:8], reply[8:]
+	conn.Write(stack(uint32(len(first))))
+	conn.Write(first)
+	conn.Write(stack(uint32(len(second)) | lastFragmentFlag))
+	conn.Write(second)
+}
+
+func TestClientCallOverTCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go tcpEchoServer(t, ln)
+
+	host, port, _ := net.SplitHostPort(ln.Addr().String())
+	cli := &Client{Host: host, Port: port, Network: "tcp"}
+
+	if err := cli.Call(context.Background(), 1, 1, 1, voidArgs{}, nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// tcpOversizedFragmentServer accepts one connection, reads the request, and
+// replies with a record marker claiming a fragment larger than
+// maxReplySize, without ever writing that much data.
+func tcpOversizedFragmentServer(t *testing.T, ln net.Listener) {
+	t.Helper()
+	conn, err := ln.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	var hdr [4]byte
+	if err := readFull(conn, hdr[:]); err != nil {
+		return
+	}
+	rec := binary.BigEndian.Uint32(hdr[:])
+	req := make([]byte, rec&^lastFragmentFlag)
+	readFull(conn, req)
+
+	conn.Write(stack((maxReplySize + 1) | lastFragmentFlag))
+}
+
+func TestClientCallOverTCPRejectsOversizedFragment(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go tcpOversizedFragmentServer(t, ln)
+
+	host, port, _ := net.SplitHostPort(ln.Addr().String())
+	cli := &Client{Host: host, Port: port, Network: "tcp", Timeout: time.Second}
+
+	if err := cli.Call(context.Background(), 1, 1, 1, voidArgs{}, nil); err == nil || !strings.Contains(err.Error(), "exceeds") {
+		t.Fatalf("got %v, want an error about exceeding the reply size limit", err)
+	}
+}
@@ -0,0 +1,89 @@
+package rpc
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+// xdrPad rounds n up to the next multiple of 4, per the XDR padding rule
+// (RFC 1014 §3.11).
+func xdrPad(n uint32) uint32 { return (n + 3) &^ 3 }
+
+// stack encodes a set of uint32 values in big endian order as a byte slice.
+func stack(words ...uint32) []byte {
+	b := make([]byte, len(words)*4)
+	for i, w := range words {
+		binary.BigEndian.PutUint32(b[i*4:], w)
+	}
+	return b
+}
+
+// encodeString encodes s as an XDR string: a length-prefixed byte sequence
+// padded with zeros to a multiple of 4 bytes.
+func encodeString(s string) []byte {
+	n := uint32(len(s))
+	b := make([]byte, 4+xdrPad(n))
+	binary.BigEndian.PutUint32(b, n)
+	copy(b[4:], s)
+	return b
+}
+
+// encodeUint32Array encodes vals as an XDR variable-length array of
+// unsigned integers.
+func encodeUint32Array(vals []uint32) []byte {
+	b := stack(uint32(len(vals)))
+	for _, v := range vals {
+		b = append(b, stack(v)...)
+	}
+	return b
+}
+
+// Reader decodes a sequence of XDR-encoded values out of a reply body.
+// Once a read fails, every subsequent read is a no-op and returns the zero
+// value; callers should make all the reads they need and check Err once at
+// the end, rather than after every call.
+type Reader struct {
+	data []byte
+	err  error
+}
+
+// NewReader returns a Reader over data.
+func NewReader(data []byte) *Reader { return &Reader{data: data} }
+
+// Err returns the first error encountered, if any.
+func (r *Reader) Err() error { return r.err }
+
+// Uint32 reads a single big-endian unsigned integer.
+func (r *Reader) Uint32() uint32 {
+	if r.err != nil {
+		return 0
+	}
+	if len(r.data) < 4 {
+		r.err = io.ErrUnexpectedEOF
+		return 0
+	}
+	v := binary.BigEndian.Uint32(r.data[:4])
+	r.data = r.data[4:]
+	return v
+}
+
+// Bool reads an XDR boolean (a uint32 that is zero or one).
+func (r *Reader) Bool() bool { return r.Uint32() != 0 }
+
+// FixedUint32Array reads a fixed-size XDR array of n unsigned integers.
+func (r *Reader) FixedUint32Array(n int) []uint32 {
+	if r.err != nil || n == 0 {
+		return nil
+	}
+	out := make([]uint32, n)
+	for i := range out {
+		out[i] = r.Uint32()
+	}
+	return out
+}
+
+// Uint32Array reads an XDR variable-length array of unsigned integers: a
+// length prefix followed by that many elements.
+func (r *Reader) Uint32Array() []uint32 {
+	return r.FixedUint32Array(int(r.Uint32()))
+}
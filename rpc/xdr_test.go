@@ -0,0 +1,78 @@
+package rpc
+
+import (
+	"io"
+	"testing"
+)
+
+func TestReaderUint32AndBool(t *testing.T) {
+	r := NewReader(stack(1, 0))
+	if got := r.Uint32(); got != 1 {
+		t.Errorf("got %d, want 1", got)
+	}
+	if got := r.Bool(); got != false {
+		t.Errorf("got %v, want false", got)
+	}
+	if err := r.Err(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestReaderFixedUint32Array(t *testing.T) {
+	r := NewReader(stack(10, 20, 30))
+	got := r.FixedUint32Array(3)
+	want := []uint32{10, 20, 30}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("got[%d] = %d, want %d", i, got[i], want[i])
+		}
+	}
+	if err := r.Err(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestReaderFixedUint32ArrayShort(t *testing.T) {
+	r := NewReader(stack(10, 20))
+	r.FixedUint32Array(3)
+	if err := r.Err(); err != io.ErrUnexpectedEOF {
+		t.Errorf("got err %v, want %v", err, io.ErrUnexpectedEOF)
+	}
+}
+
+func TestReaderUint32Array(t *testing.T) {
+	r := NewReader(append(stack(2), stack(10, 20)...))
+	got := r.Uint32Array()
+	want := []uint32{10, 20}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("got[%d] = %d, want %d", i, got[i], want[i])
+		}
+	}
+	if err := r.Err(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestReaderErrIsSticky(t *testing.T) {
+	r := NewReader(nil)
+	if got := r.Uint32(); got != 0 {
+		t.Errorf("got %d, want 0", got)
+	}
+	if err := r.Err(); err != io.ErrUnexpectedEOF {
+		t.Errorf("got err %v, want %v", err, io.ErrUnexpectedEOF)
+	}
+	// Once err is set, every subsequent read is a no-op.
+	if got := r.Uint32(); got != 0 {
+		t.Errorf("got %d, want 0 after error", got)
+	}
+	if got := r.FixedUint32Array(2); got != nil {
+		t.Errorf("got %v, want nil after error", got)
+	}
+}
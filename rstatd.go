@@ -21,24 +21,73 @@ For remote instances, construct a client before making the call.
 
 If the port is left empty, the client will request the daemon's port from
 rpcbind, which is assumed to be accessable at port 111.
+
+Every call has a context-aware counterpart (ReadStatsContext) that accepts a
+context.Context for cancellation and deadlines.
+
+   stats, err := cli.ReadStatsContext(ctx)
+
+Under the hood, Client is a thin wrapper around the rstatd program
+(100001) built on the general-purpose Sun RPC client in the rpc
+subpackage. ReadStats and ReadStatsContext always speak RstatVersTime, the
+newest of the three protocol versions the daemon may support; use
+Client.Stats to target an older version directly.
 */
 package rstatd
 
 import (
-	"encoding/binary"
+	"context"
 	"fmt"
-	"math/rand"
-	"net"
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/yhat/rstatd/rpc"
+)
+
+const (
+	rstatProg         = 100001
+	rstatProcStats    = 1
+	rstatProcHaveDisk = 2
+)
+
+// rstat protocol versions. RstatVersOrig is the oldest and leanest;
+// RstatVersTime is the newest and is what ReadStats/ReadStatsContext use.
+const (
+	// RstatVersOrig (RSTATVERS_ORIG) is the original rstat protocol: CPU
+	// times, disk transfers, paging, interrupts, and network counters.
+	RstatVersOrig = 1
+	// RstatVersSwtch (RSTATVERS_SWTCH) adds the context switch counter and
+	// the 1/5/15 minute load averages.
+	RstatVersSwtch = 2
+	// RstatVersTime (RSTATVERS_TIME) adds the daemon's boot time, current
+	// time, and outbound packet count.
+	RstatVersTime = 3
 )
 
+// fscale is the kernel's fixed-point scaling factor (FSCALE) applied to
+// avenrun; dividing by it yields the conventional load average numbers.
+const fscale = 256
+
+// dkNDrive is DK_NDRIVE, the fixed number of disk transfer counters the
+// stats/statsswtch/statstime structs carry on the wire.
+const dkNDrive = 4
+
+// avenrunLen is the fixed number of load average samples (1, 5, and 15
+// minute) carried in avenrun.
+const avenrunLen = 3
+
 var localhostClient = &Client{}
 
 // ReadStats returns stats from localhost.
 func ReadStats() (*Stats, error) { return localhostClient.ReadStats() }
 
+// ReadStatsContext returns stats from localhost, aborting early if ctx is
+// cancelled or its deadline elapses.
+func ReadStatsContext(ctx context.Context) (*Stats, error) {
+	return localhostClient.ReadStatsContext(ctx)
+}
+
 type Client struct {
 	// The hostname of the rstatd server.
 	// If empty, '0.0.0.0' is implied.
@@ -47,15 +96,63 @@ type Client struct {
 	// If empty, the client will request the daemon's port from the
 	// rpcbind process at port 111.
 	Port string
+
+	// Timeout is the deadline for a single RPC attempt. If zero, the
+	// rpc package's default is used.
+	Timeout time.Duration
+	// Retries is the number of times a request is resent after a read
+	// timeout before giving up. If zero, the rpc package's default is
+	// used.
+	Retries int
+	// Backoff is the base delay before the first retry; each subsequent
+	// retry doubles the previous delay. If zero, the rpc package's
+	// default is used.
+	Backoff time.Duration
 }
 
+// rpcClient builds the general-purpose RPC client used to talk to the
+// rstatd daemon (or rpcbind) on port.
+func (c *Client) rpcClient(port string) *rpc.Client {
+	return &rpc.Client{
+		Host:    c.Host,
+		Port:    port,
+		Timeout: c.Timeout,
+		Retries: c.Retries,
+		Backoff: c.Backoff,
+	}
+}
+
+// port resolves the port the rstatd daemon for the given version is
+// listening on, querying rpcbind if the client wasn't given one explicitly.
+func (c *Client) port(ctx context.Context, version uint32) (string, error) {
+	port := strings.TrimLeft(c.Port, ":")
+	if port != "" {
+		return port, nil
+	}
+	pm := &rpc.Portmap{Client: c.rpcClient("111")}
+	p, err := pm.GetPort(ctx, rstatProg, version, rpc.ProtoUDP)
+	if err != nil {
+		return "", fmt.Errorf("rstatd: %v", err)
+	}
+	return strconv.FormatUint(uint64(p), 10), nil
+}
+
+// Stats holds the counters reported by RSTATPROC_STATS. Which fields are
+// populated depends on Version: ContextSwitches and AverageRunQueryLen
+// require RstatVersSwtch or newer, and BootTime/CurrTime/NetOPackets
+// require RstatVersTime.
 type Stats struct {
+	// Version is the rstat protocol version this Stats was decoded from.
+	Version int
+
 	CPUUser uint32
 	CPUNice uint32
 	CPUSys  uint32
 	CPUIdle uint32
 
-	DiskTransfers [4]uint32
+	// DiskTransfers holds dkNDrive (DK_NDRIVE) transfer counters, one per
+	// disk drive slot; unused slots are reported as zero.
+	DiskTransfers []uint32
 
 	PagesIn  uint32
 	PagesOut uint32
@@ -63,17 +160,23 @@ type Stats struct {
 	PageSwapsIn  uint32
 	PageSwapsOut uint32
 
-	Interrupts      uint32
+	Interrupts uint32
+	// ContextSwitches is only populated for Version >= RstatVersSwtch.
 	ContextSwitches uint32
 
-	NetIPackets   uint32
-	NetIErrors    uint32
+	NetIPackets uint32
+	NetIErrors  uint32
+	// NetOPackets is only populated for Version == RstatVersTime.
 	NetOPackets   uint32
 	NetOErrors    uint32
 	NetCollisions uint32
 
-	AverageRunQueryLen [3]uint32
+	// AverageRunQueryLen holds the 1, 5, and 15 minute load averages,
+	// already divided by FSCALE. Only populated for Version >=
+	// RstatVersSwtch.
+	AverageRunQueryLen []uint32
 
+	// BootTime and CurrTime are only populated for Version == RstatVersTime.
 	BootTime time.Time
 	CurrTime time.Time
 }
@@ -90,173 +193,118 @@ type Stats struct {
 // 	int if_ierrors;
 // 	int if_oerrors;
 // 	int if_collisions;
-// 	unsigned int v_swtch;
-// 	int avenrun[3];         /* scaled by FSCALE */
-// 	rstat_timeval boottime;
-// 	rstat_timeval curtime;
-// 	int if_opackets;
+// 	unsigned int v_swtch;		/* RSTATVERS_SWTCH and newer */
+// 	int avenrun[3];         	/* RSTATVERS_SWTCH and newer, scaled by FSCALE */
+// 	rstat_timeval boottime;		/* RSTATVERS_TIME only */
+// 	rstat_timeval curtime;		/* RSTATVERS_TIME only */
+// 	int if_opackets;		/* RSTATVERS_TIME only */
 // };
 
-// ReadStats reads the stats from the machine.
+// ReadStats reads the stats from the machine using RstatVersTime.
 // If the port of the client is not specified.
 func (c *Client) ReadStats() (*Stats, error) {
-	s := new(Stats)
-	port := strings.TrimLeft(c.Port, ":")
-	if port == "" {
-		p, err := rstatdPort()
-		if err != nil {
-			return nil, err
-		}
-		port = strconv.FormatUint(uint64(p), 10)
+	return c.ReadStatsContext(context.Background())
+}
+
+// ReadStatsContext reads the stats from the machine using RstatVersTime,
+// aborting early if ctx is cancelled or its deadline elapses.
+// If the port of the client is not specified.
+func (c *Client) ReadStatsContext(ctx context.Context) (*Stats, error) {
+	return c.Stats(ctx, RstatVersTime)
+}
+
+// Stats reads the stats from the machine using the given rstat protocol
+// version (RstatVersOrig, RstatVersSwtch, or RstatVersTime), aborting early
+// if ctx is cancelled or its deadline elapses. Use this directly to talk to
+// a daemon that doesn't support RstatVersTime.
+func (c *Client) Stats(ctx context.Context, version int) (*Stats, error) {
+	switch version {
+	case RstatVersOrig, RstatVersSwtch, RstatVersTime:
+	default:
+		return nil, fmt.Errorf("rstatd: unsupported rstat version %d", version)
 	}
-	rawResp, err := c.readStats(c.Host + ":" + port)
+
+	port, err := c.port(ctx, uint32(version))
 	if err != nil {
 		return nil, err
 	}
-	n := len(rawResp)
-	if n < 116 {
-		return nil, fmt.Errorf("rstatd: bad response length from daemon. expected at least 116 bytes, got %d", n)
-	}
 
-	// the first 12 bytes of the response aren't relavent
-	rawResp = rawResp[12:]
-
-	next := func() uint32 {
-		v := binary.BigEndian.Uint32(rawResp[:4])
-		rawResp = rawResp[4:]
-		return v
+	reply := &statsReply{version: version}
+	if err := c.rpcClient(port).Call(ctx, rstatProg, uint32(version), rstatProcStats, voidArgs{}, reply); err != nil {
+		return nil, fmt.Errorf("rstatd: daemon request failed: %v", err)
 	}
+	return reply.stats, nil
+}
 
-	s.CPUUser, s.CPUNice, s.CPUSys, s.CPUIdle = next(), next(), next(), next()
-	for i := 0; i < 4; i++ {
-		s.DiskTransfers[i] = next()
-	}
-	s.PagesIn, s.PagesOut = next(), next()
-	s.PageSwapsIn, s.PageSwapsOut = next(), next()
-	s.Interrupts = next()
-	s.NetIPackets, s.NetIErrors = next(), next()
-	s.NetOErrors, s.NetCollisions = next(), next()
-	s.ContextSwitches = next()
-	for i := 0; i < 3; i++ {
-		s.AverageRunQueryLen[i] = next() / 256
+// HaveDisk reports whether the machine has a disk, per RSTATPROC_HAVEDISK.
+func (c *Client) HaveDisk(ctx context.Context) (bool, error) {
+	port, err := c.port(ctx, RstatVersTime)
+	if err != nil {
+		return false, err
 	}
 
-	s.BootTime = time.Unix(int64(next()), int64(next()))
-	s.CurrTime = time.Unix(int64(next()), int64(next()))
-	s.NetOPackets = next()
-	return s, nil
+	reply := new(haveDiskReply)
+	if err := c.rpcClient(port).Call(ctx, rstatProg, RstatVersTime, rstatProcHaveDisk, voidArgs{}, reply); err != nil {
+		return false, fmt.Errorf("rstatd: daemon request failed: %v", err)
+	}
+	return reply.haveDisk, nil
 }
 
-// stack encodes a set of uint32 values in big endian order as a byte slice.
-func stack(words ...uint32) []byte {
-	b := make([]byte, len(words)*4)
-	wordBuff := make([]byte, 4)
+// voidArgs encodes an empty RPC argument list, used by every rstat
+// procedure: none of them take arguments.
+type voidArgs struct{}
 
-	for i, w := range words {
-		binary.BigEndian.PutUint32(wordBuff, w)
-		offset := i * 4
-		for j := 0; j < 4; j++ {
-			b[offset+j] = wordBuff[j]
-		}
-	}
-	return b
-}
+func (voidArgs) XDREncode() ([]byte, error) { return nil, nil }
 
-func (c *Client) readStats(addr string) ([]byte, error) {
-	conn, err := net.Dial("udp", addr)
-	if err != nil {
-		return nil, fmt.Errorf("rstatd: failed to connect to daemon %v", err)
-	}
-	defer conn.Close()
-	tId := rand.Uint32()
-	req := stack(
-		tId,        // transaction id
-		0x00000000, // request type (CALL)
-		0x00000002, // rpc version
-		0x000186a1, // program (rstat)
-		0x00000003, // version
-		0x00000001, // procedure
-		0x00000000,
-		0x00000000,
-		0x00000000,
-		0x00000000,
-	)
-	resp, err := doRPCTrans(conn, req, tId)
-	if err != nil {
-		return nil, fmt.Errorf("rstatd: daemon request failed: %v", err)
-	}
-	return resp, nil
+// statsReply decodes an RSTATPROC_STATS reply for a specific protocol
+// version into a Stats.
+type statsReply struct {
+	version int
+	stats   *Stats
 }
 
-// doRPCTrans performs an RPC transaction and validates the response.
-func doRPCTrans(conn net.Conn, req []byte, transId uint32) ([]byte, error) {
-	if _, err := conn.Write(req); err != nil {
-		return nil, fmt.Errorf("failed to write request %v", err)
-	}
-	resp := make([]byte, 2048)
-	n, err := conn.Read(resp)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response %v", err)
-	}
-	if n < 12 {
-		return nil, fmt.Errorf("invalid response length %d", n)
-	}
-	resp = resp[:n]
+func (r *statsReply) XDRDecode(data []byte) error {
+	x := rpc.NewReader(data)
+	s := &Stats{Version: r.version}
 
-	next := func() uint32 {
-		v := binary.BigEndian.Uint32(resp[:4])
-		resp = resp[4:]
-		return v
-	}
-	if next() != transId {
-		return nil, fmt.Errorf("transcation id mismatch from rpc request")
+	s.CPUUser, s.CPUNice, s.CPUSys, s.CPUIdle = x.Uint32(), x.Uint32(), x.Uint32(), x.Uint32()
+	s.DiskTransfers = x.FixedUint32Array(dkNDrive)
+	s.PagesIn, s.PagesOut = x.Uint32(), x.Uint32()
+	s.PageSwapsIn, s.PageSwapsOut = x.Uint32(), x.Uint32()
+	s.Interrupts = x.Uint32()
+	s.NetIPackets, s.NetIErrors = x.Uint32(), x.Uint32()
+	s.NetOErrors, s.NetCollisions = x.Uint32(), x.Uint32()
+
+	if r.version >= RstatVersSwtch {
+		s.ContextSwitches = x.Uint32()
+		s.AverageRunQueryLen = x.FixedUint32Array(avenrunLen)
+		for i := range s.AverageRunQueryLen {
+			s.AverageRunQueryLen[i] /= fscale
+		}
 	}
-	if next() != 0x01 {
-		return nil, fmt.Errorf("invalid response from rpc request")
+	if r.version >= RstatVersTime {
+		s.BootTime = time.Unix(int64(x.Uint32()), int64(x.Uint32()))
+		s.CurrTime = time.Unix(int64(x.Uint32()), int64(x.Uint32()))
+		s.NetOPackets = x.Uint32()
 	}
-	if next() != 0x00 {
-		return nil, fmt.Errorf("rpc request failed")
+
+	if err := x.Err(); err != nil {
+		return fmt.Errorf("bad response from daemon: %v", err)
 	}
-	return resp, nil
+	r.stats = s
+	return nil
 }
 
-// rstatdPort asks the local rpcbind process what port the rstatd process
-// is listening on
-func rstatdPort() (uint32, error) {
-	conn, err := net.Dial("udp", "0.0.0.0:111")
-	if err != nil {
-		return 0, fmt.Errorf("rstatd: failed to dial rpcbind service %v", err)
-	}
-	defer conn.Close()
-	tId := rand.Uint32()
-	req := stack(
-		tId,        // transaction id
-		0x00000000, // request type (CALL)
-		0x00000002, // rpc version
-		0x000186a0, // program (portmap)
-		0x00000002, // version
-		0x00000003, // procedure
-		0x00000000,
-		0x00000000,
-		0x00000000,
-		0x00000000,
-		0x000186a1, // program to look up (rstat)
-		0x00000003, // version
-		0x00000011, // protocol (UDP)
-		0x00000000,
-	)
-	resp, err := doRPCTrans(conn, req, tId)
-	if err != nil {
-		return 0, fmt.Errorf("rstatd: rpcbind request failed: %v", err)
-	}
-	n := len(resp)
-	if n < 4 {
-		return 0, fmt.Errorf("rstatd: no respose from rpcbind")
-	}
+// haveDiskReply decodes an RSTATPROC_HAVEDISK reply.
+type haveDiskReply struct {
+	haveDisk bool
+}
 
-	port := binary.BigEndian.Uint32(resp[n-4 : n])
-	if port == 0 {
-		return 0, fmt.Errorf("rstatd: no port mapping found for rstatd")
+func (r *haveDiskReply) XDRDecode(data []byte) error {
+	x := rpc.NewReader(data)
+	r.haveDisk = x.Bool()
+	if err := x.Err(); err != nil {
+		return fmt.Errorf("bad response from daemon: %v", err)
 	}
-	return port, nil
+	return nil
 }
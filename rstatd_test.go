@@ -1,35 +1,21 @@
 package rstatd
 
 import (
-	"strconv"
+	"context"
+	"encoding/binary"
+	"strings"
 	"testing"
 	"time"
 )
 
-func TestRstatdPort(t *testing.T) {
-	port, err := rstatdPort()
-	if err != nil {
-		t.Fatal(err)
-	}
-	if port == 0 {
-		t.Errorf("expected port to be non-zero")
-	}
-}
-
-func TestFetch(t *testing.T) {
-	cli := new(Client)
-	port, err := rstatdPort()
-	if err != nil {
-		t.Fatal(err)
-	}
-	res, err := cli.readStats("0.0.0.0:" + strconv.FormatUint(uint64(port), 10))
-	if err != nil {
-		t.Errorf("failed to fetch stats %v", err)
-		return
-	}
-	if len(res) < 116 {
-		t.Errorf("short response length %d", len(res))
+// stack encodes a set of uint32 values in big endian order, mirroring the
+// XDR wire format statsReply.XDRDecode expects.
+func stack(words ...uint32) []byte {
+	b := make([]byte, len(words)*4)
+	for i, w := range words {
+		binary.BigEndian.PutUint32(b[i*4:], w)
 	}
+	return b
 }
 
 func TestReadStatsWithClient(t *testing.T) {
@@ -62,3 +48,79 @@ func TestReadStats(t *testing.T) {
 		t.Errorf("curr time %s is after end %s", stats.CurrTime, end)
 	}
 }
+
+func TestReadStatsContextCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := ReadStatsContext(ctx)
+	if err == nil || !strings.Contains(err.Error(), context.Canceled.Error()) {
+		t.Errorf("expected an error wrapping context.Canceled, got %v", err)
+	}
+}
+
+func TestReadStatsContextDeadlineExceeded(t *testing.T) {
+	cli := &Client{Host: "192.0.2.1", Port: "792", Timeout: time.Second, Retries: 1, Backoff: time.Millisecond}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := cli.ReadStatsContext(ctx); err == nil {
+		t.Errorf("expected an error reaching an unreachable host")
+	}
+}
+
+func TestHaveDisk(t *testing.T) {
+	cli := new(Client)
+	if _, err := cli.HaveDisk(context.Background()); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestStatsUnsupportedVersion(t *testing.T) {
+	cli := new(Client)
+	if _, err := cli.Stats(context.Background(), 4); err == nil {
+		t.Errorf("expected an unsupported version error")
+	}
+}
+
+func TestStatsReplyDecodeByVersion(t *testing.T) {
+	// dk_xfer[DK_NDRIVE]: a fixed 4-element array, no length prefix.
+	disks := []uint32{1, 2, 3, 4}
+
+	origBody := append(stack(10, 11, 12, 13), stack(disks...)...)
+	origBody = append(origBody, stack(20, 21, 22, 23, 24, 30, 31, 32, 33)...)
+
+	reply := &statsReply{version: RstatVersOrig}
+	if err := reply.XDRDecode(origBody); err != nil {
+		t.Fatal(err)
+	}
+	s := reply.stats
+	if s.Version != RstatVersOrig {
+		t.Errorf("got version %d, want %d", s.Version, RstatVersOrig)
+	}
+	if len(s.DiskTransfers) != len(disks) {
+		t.Errorf("got %d disk counters, want %d", len(s.DiskTransfers), len(disks))
+	}
+	if s.ContextSwitches != 0 || s.AverageRunQueryLen != nil {
+		t.Errorf("RstatVersOrig should not populate v2+ fields, got %+v", s)
+	}
+
+	// v_swtch, then avenrun[3]: another fixed array, no length prefix.
+	swtchBody := append(append([]byte{}, origBody...), stack(40)...)
+	swtchBody = append(swtchBody, stack(256, 512, 768)...)
+	reply = &statsReply{version: RstatVersSwtch}
+	if err := reply.XDRDecode(swtchBody); err != nil {
+		t.Fatal(err)
+	}
+	s = reply.stats
+	if s.ContextSwitches != 40 {
+		t.Errorf("got context switches %d, want 40", s.ContextSwitches)
+	}
+	want := []uint32{1, 2, 3}
+	for i, v := range want {
+		if s.AverageRunQueryLen[i] != v {
+			t.Errorf("avenrun[%d] = %d, want %d", i, s.AverageRunQueryLen[i], v)
+		}
+	}
+	if !s.BootTime.IsZero() || !s.CurrTime.IsZero() {
+		t.Errorf("RstatVersSwtch should not populate v3 fields, got %+v", s)
+	}
+}
@@ -0,0 +1,131 @@
+package rstatd
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// Sample holds the rates derived from two consecutive Stats readings, along
+// with the most recent raw reading they came from.
+type Sample struct {
+	// Stats is the raw reading the rates below were derived from.
+	Stats *Stats
+
+	// CPUUserPct, CPUSysPct, and CPUIdlePct are the share of CPU time
+	// spent in each state since the previous reading, as a percentage of
+	// user+nice+sys+idle; the nice share is folded into that denominator
+	// but isn't broken out as its own field.
+	CPUUserPct float64
+	CPUSysPct  float64
+	CPUIdlePct float64
+
+	// PagesInPerSec is the rate of pages paged in since the previous
+	// reading.
+	PagesInPerSec float64
+
+	// InterruptsPerSec is the rate of interrupts since the previous
+	// reading.
+	InterruptsPerSec float64
+
+	// NetIPacketsPerSec is the rate of inbound network packets since the
+	// previous reading.
+	NetIPacketsPerSec float64
+
+	// LoadAvg holds the 1, 5, and 15 minute load averages, copied from
+	// Stats.AverageRunQueryLen (unset if the daemon didn't report them).
+	LoadAvg [3]float64
+}
+
+// Watch polls the machine at interval, emitting a Sample on the returned
+// channel each time it derives rates from two consecutive readings. The
+// first reading never produces a Sample, since there's nothing yet to
+// diff it against.
+//
+// Watch stops and closes both channels when ctx is cancelled, or after
+// sending at most one error on the error channel. Callers should read from
+// both channels until they close.
+func (c *Client) Watch(ctx context.Context, interval time.Duration) (<-chan *Sample, <-chan error) {
+	samples := make(chan *Sample)
+	errs := make(chan error, 1)
+
+	go func() {
+		defer close(samples)
+		defer close(errs)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		var prev *Stats
+		for {
+			cur, err := c.ReadStatsContext(ctx)
+			if err != nil {
+				select {
+				case errs <- err:
+				case <-ctx.Done():
+				}
+				return
+			}
+
+			if prev != nil {
+				if sample, ok := diffStats(prev, cur); ok {
+					select {
+					case samples <- sample:
+					case <-ctx.Done():
+						return
+					}
+				}
+			}
+			prev = cur
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return samples, errs
+}
+
+// diffStats derives a Sample from two consecutive readings. It reports
+// false if the daemon's clock hasn't advanced between them, since rates
+// can't be derived without an elapsed duration.
+func diffStats(prev, cur *Stats) (*Sample, bool) {
+	elapsed := cur.CurrTime.Sub(prev.CurrTime).Seconds()
+	if elapsed <= 0 {
+		return nil, false
+	}
+
+	dUser := delta32(prev.CPUUser, cur.CPUUser)
+	dNice := delta32(prev.CPUNice, cur.CPUNice)
+	dSys := delta32(prev.CPUSys, cur.CPUSys)
+	dIdle := delta32(prev.CPUIdle, cur.CPUIdle)
+
+	s := &Sample{Stats: cur}
+	if total := float64(dUser + dNice + dSys + dIdle); total > 0 {
+		s.CPUUserPct = float64(dUser) / total * 100
+		s.CPUSysPct = float64(dSys) / total * 100
+		s.CPUIdlePct = float64(dIdle) / total * 100
+	}
+
+	s.PagesInPerSec = float64(delta32(prev.PagesIn, cur.PagesIn)) / elapsed
+	s.InterruptsPerSec = float64(delta32(prev.Interrupts, cur.Interrupts)) / elapsed
+	s.NetIPacketsPerSec = float64(delta32(prev.NetIPackets, cur.NetIPackets)) / elapsed
+
+	for i := 0; i < len(s.LoadAvg) && i < len(cur.AverageRunQueryLen); i++ {
+		s.LoadAvg[i] = float64(cur.AverageRunQueryLen[i])
+	}
+
+	return s, true
+}
+
+// delta32 computes curr-prev for a monotonically increasing 32-bit daemon
+// counter, accounting for the counter wrapping around past math.MaxUint32.
+func delta32(prev, curr uint32) uint32 {
+	if curr >= prev {
+		return curr - prev
+	}
+	return uint32(math.MaxUint32-prev) + curr + 1
+}
@@ -0,0 +1,99 @@
+package rstatd
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestDelta32(t *testing.T) {
+	cases := []struct {
+		prev, curr, want uint32
+	}{
+		{10, 15, 5},
+		{15, 15, 0},
+		{math.MaxUint32 - 1, 2, 4},
+	}
+	for _, c := range cases {
+		if got := delta32(c.prev, c.curr); got != c.want {
+			t.Errorf("delta32(%d, %d) = %d, want %d", c.prev, c.curr, got, c.want)
+		}
+	}
+}
+
+func TestDiffStats(t *testing.T) {
+	t0 := time.Unix(1000, 0)
+	prev := &Stats{
+		CPUUser: 100, CPUNice: 0, CPUSys: 50, CPUIdle: 850,
+		PagesIn: 10, Interrupts: 200, NetIPackets: 1000,
+		AverageRunQueryLen: []uint32{1, 2, 3},
+		CurrTime:           t0,
+	}
+	cur := &Stats{
+		CPUUser: 150, CPUNice: 0, CPUSys: 60, CPUIdle: 1790,
+		PagesIn: 30, Interrupts: 400, NetIPackets: 1200,
+		AverageRunQueryLen: []uint32{2, 2, 3},
+		CurrTime:           t0.Add(2 * time.Second),
+	}
+
+	sample, ok := diffStats(prev, cur)
+	if !ok {
+		t.Fatal("expected a sample")
+	}
+	if got, want := sample.PagesInPerSec, 10.0; got != want {
+		t.Errorf("PagesInPerSec = %v, want %v", got, want)
+	}
+	if got, want := sample.InterruptsPerSec, 100.0; got != want {
+		t.Errorf("InterruptsPerSec = %v, want %v", got, want)
+	}
+	if got, want := sample.NetIPacketsPerSec, 100.0; got != want {
+		t.Errorf("NetIPacketsPerSec = %v, want %v", got, want)
+	}
+	if sample.LoadAvg != [3]float64{2, 2, 3} {
+		t.Errorf("LoadAvg = %v, want %v", sample.LoadAvg, [3]float64{2, 2, 3})
+	}
+
+	// dUser=50, dSys=10, dIdle=940, total=1000.
+	if got, want := sample.CPUUserPct, 5.0; math.Abs(got-want) > 1e-9 {
+		t.Errorf("CPUUserPct = %v, want %v", got, want)
+	}
+	if got, want := sample.CPUSysPct, 1.0; math.Abs(got-want) > 1e-9 {
+		t.Errorf("CPUSysPct = %v, want %v", got, want)
+	}
+	if got, want := sample.CPUIdlePct, 94.0; math.Abs(got-want) > 1e-9 {
+		t.Errorf("CPUIdlePct = %v, want %v", got, want)
+	}
+}
+
+func TestDiffStatsNoElapsedTime(t *testing.T) {
+	t0 := time.Unix(1000, 0)
+	prev := &Stats{CurrTime: t0}
+	cur := &Stats{CurrTime: t0}
+	if _, ok := diffStats(prev, cur); ok {
+		t.Errorf("expected no sample when the daemon clock hasn't advanced")
+	}
+}
+
+func TestWatchStopsOnCancel(t *testing.T) {
+	cli := new(Client)
+	ctx, cancel := context.WithCancel(context.Background())
+	samples, errs := cli.Watch(ctx, 5*time.Millisecond)
+	cancel()
+
+	timeout := time.After(time.Second)
+	for samples != nil || errs != nil {
+		select {
+		case _, ok := <-samples:
+			if !ok {
+				samples = nil
+			}
+		case _, ok := <-errs:
+			if !ok {
+				errs = nil
+			}
+		case <-timeout:
+			t.Fatal("Watch did not stop after ctx was cancelled")
+		}
+	}
+}